@@ -1,4 +1,9 @@
-package main
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
 
 // ----------------------------------------------------------------------------
 
@@ -8,5 +13,27 @@ type Error int
 const (
 	// ErrMaxRetries is returned when the called function failed after the
 	// maximum number of allowed tries.
-	ErrMaxRetries = iota
+	ErrMaxRetries Error = iota
+
+	// ErrWriteBufferFull is returned by Write when the opt-in write buffer
+	// enabled via EnableWriteBuffer has no room left for the bytes that
+	// could not be written to a live connection.
+	ErrWriteBufferFull
+
+	// ErrReconnectRateLimited is returned when reconnect is denied by the
+	// rate limit configured via SetReconnectRateLimit.
+	ErrReconnectRateLimited
 )
+
+func (e Error) Error() string {
+	switch e {
+	case ErrMaxRetries:
+		return "gas: max retries reached"
+	case ErrWriteBufferFull:
+		return "gas: write buffer full"
+	case ErrReconnectRateLimited:
+		return "gas: reconnect rate limited"
+	default:
+		return "gas: unknown error"
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+
+func TestTCPClient_Hooks(t *testing.T) {
+	s, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := s.Addr()
+
+	c, err := Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tc := c.(*TCPClient)
+	tc.SetMaxRetries(50)
+	tc.SetRetryInterval(10 * time.Millisecond)
+
+	var (
+		disconnected       = make(chan error, 1)
+		reconnectAttempted = make(chan int, 1)
+		reconnectSucceeded = make(chan net.Conn, 1)
+	)
+	tc.SetOnDisconnect(func(err error) {
+		select {
+		case disconnected <- err:
+		default:
+		}
+	})
+	tc.SetOnReconnectAttempt(func(attempt int, delay time.Duration) {
+		select {
+		case reconnectAttempted <- attempt:
+		default:
+		}
+	})
+	tc.SetOnReconnectSuccess(func(conn net.Conn) {
+		select {
+		case reconnectSucceeded <- conn:
+		default:
+		}
+	})
+
+	// Kill and restart the server so the client has to live through a real
+	// disconnect/reconnect cycle.
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s2, err := net.Listen("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := c.Write([]byte("x")); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Error("OnDisconnect was never fired")
+	}
+	select {
+	case <-reconnectAttempted:
+	case <-time.After(time.Second):
+		t.Error("OnReconnectAttempt was never fired")
+	}
+	select {
+	case <-reconnectSucceeded:
+	case <-time.After(time.Second):
+		t.Error("OnReconnectSuccess was never fired")
+	}
+
+	if stats := tc.Stats(); stats.Reconnects == 0 {
+		t.Error("Stats().Reconnects was never incremented")
+	}
+}
+
+func TestTCPClient_SetOnGiveUp(t *testing.T) {
+	s, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := s.Addr()
+
+	c, err := Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tc := c.(*TCPClient)
+	tc.SetMaxRetries(2)
+	tc.SetRetryInterval(5 * time.Millisecond)
+
+	gaveUp := make(chan error, 1)
+	tc.SetOnGiveUp(func(err error) {
+		select {
+		case gaveUp <- err:
+		default:
+		}
+	})
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Write([]byte("x")); err != ErrMaxRetries {
+		t.Fatalf("got %v, want ErrMaxRetries against a dead server", err)
+	}
+
+	select {
+	case err := <-gaveUp:
+		if err != ErrMaxRetries {
+			t.Errorf("got %v, want ErrMaxRetries", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("OnGiveUp was never fired")
+	}
+
+	if stats := tc.Stats(); stats.LastError == nil {
+		t.Error("Stats().LastError was never recorded")
+	}
+}
@@ -0,0 +1,802 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ----------------------------------------------------------------------------
+
+// Dialer establishes the connection a reconnector should (re)dial whenever
+// the one it is holding dies. Implementations are free to carry whatever
+// state they need (remote address, TLS config, ...) to redial from scratch.
+type Dialer interface {
+	Dial(ctx context.Context) (net.Conn, error)
+}
+
+// DialerFunc adapts a plain function to a Dialer.
+type DialerFunc func(ctx context.Context) (net.Conn, error)
+
+// Dial calls f(ctx).
+func (f DialerFunc) Dial(ctx context.Context) (net.Conn, error) {
+	return f(ctx)
+}
+
+// ----------------------------------------------------------------------------
+
+// reconnector is the transport-agnostic auto-reconnect state machine shared
+// by TCPClient, UnixClient and TLSClient. It owns the live net.Conn plus
+// every knob that governs how it is replaced: retry limits, backoff, rate
+// limiting, write buffering, lifecycle hooks and replayed socket options.
+//
+// Adapters embed a *reconnector to get Read, Write, Close and friends for
+// free, and layer their own transport-specific methods (e.g. TCPClient's
+// SetKeepAlive) on top.
+//
+// reconnector can be safely used from multiple goroutines.
+type reconnector struct {
+	conn net.Conn
+
+	lock sync.RWMutex
+
+	dialer      Dialer
+	isRetryable func(error) bool
+
+	maxRetries       int
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+
+	// reconnectLimiter, when set via SetReconnectRateLimit, bounds how often
+	// reconnect may redial the remote peer.
+	reconnectLimiter *rate.Limiter
+
+	// sockOpts replays every option an adapter has recorded, in order, onto
+	// the freshly dialed net.Conn after each reconnect.
+	sockOpts []func(net.Conn) error
+
+	// wbuf, when non-nil, queues bytes that couldn't be written to a dead
+	// connection instead of making the caller wait out the reconnect.
+	wbuf *writeBuffer
+
+	// Lifecycle hooks, set via SetOnDisconnect, SetOnReconnectAttempt,
+	// SetOnReconnectSuccess and SetOnGiveUp. Any of them may be nil.
+	onDisconnect       func(err error)
+	onReconnectAttempt func(attempt int, delay time.Duration)
+	onReconnectSuccess func(conn net.Conn)
+	onGiveUp           func(err error)
+
+	// onConnSwap, when set by an adapter, is called every time conn is
+	// replaced, so the adapter can keep its own typed mirror of the
+	// connection (e.g. TCPClient.TCPConn()) up to date. It is internal to
+	// the adapters and distinct from the public onReconnectSuccess hook.
+	onConnSwap func(conn net.Conn)
+
+	// heartbeatInterval and heartbeatProbe, set via SetHeartbeat, drive the
+	// background prober started by heartbeatLoop. heartbeatDone is non-nil
+	// once that goroutine is running, and is closed by Close to stop it.
+	heartbeatInterval time.Duration
+	heartbeatProbe    func(conn net.Conn) error
+	heartbeatDone     chan struct{}
+
+	// closed is closed exactly once, by Close, to signal reconnectAndFlush
+	// to stop instead of retrying a dead remote forever. The heartbeat
+	// goroutine is stopped separately, through heartbeatDone.
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	stats clientStats
+}
+
+// newReconnector wraps conn with the auto-reconnect machinery, redialing
+// through dialer to replace it, and treating an error as worth reconnecting
+// over exactly when isRetryable reports true for it.
+func newReconnector(conn net.Conn, dialer Dialer, isRetryable func(error) bool) *reconnector {
+	return &reconnector{
+		conn: conn,
+
+		dialer:      dialer,
+		isRetryable: isRetryable,
+
+		maxRetries:    5,
+		retryInterval: 100 * time.Millisecond,
+
+		closed: make(chan struct{}),
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// SetMaxRetries sets the retry limit for the reconnector.
+//
+// Assuming i is the current retry iteration, the sleep time before retry i is
+// a random duration between 0 and retryInterval * (2^i), capped at
+// maxRetryInterval if one has been set via SetMaxRetryInterval.
+//
+// This function completely Lock()s the reconnector.
+func (r *reconnector) SetMaxRetries(maxRetries int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.maxRetries = maxRetries
+}
+
+// GetMaxRetries gets the retry limit for the reconnector.
+func (r *reconnector) GetMaxRetries() int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.maxRetries
+}
+
+// SetRetryInterval sets the base retry interval for the reconnector.
+//
+// This function completely Lock()s the reconnector.
+func (r *reconnector) SetRetryInterval(retryInterval time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.retryInterval = retryInterval
+}
+
+// GetRetryInterval gets the base retry interval for the reconnector.
+func (r *reconnector) GetRetryInterval() time.Duration {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.retryInterval
+}
+
+// SetMaxRetryInterval caps the exponential backoff computed from
+// retryInterval so that it stops growing past maxRetryInterval, no matter
+// how many retries have elapsed. A zero value, the default, leaves the
+// backoff uncapped.
+//
+// This function completely Lock()s the reconnector.
+func (r *reconnector) SetMaxRetryInterval(maxRetryInterval time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.maxRetryInterval = maxRetryInterval
+}
+
+// GetMaxRetryInterval gets the backoff cap configured via
+// SetMaxRetryInterval.
+func (r *reconnector) GetMaxRetryInterval() time.Duration {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.maxRetryInterval
+}
+
+// SetReconnectRateLimit bounds how often reconnect is allowed to redial the
+// remote peer, to at most events attempts per duration per. Once the limit
+// is reached, reconnect — and therefore Read, Write and ReadFrom — returns
+// ErrReconnectRateLimited instead of sleeping the caller's goroutine through
+// an unbounded retry schedule, so a client fighting a flapping peer cannot
+// burn CPU or hammer the server.
+//
+// This function completely Lock()s the reconnector.
+func (r *reconnector) SetReconnectRateLimit(events int, per time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.reconnectLimiter = rate.NewLimiter(rate.Limit(float64(events)/per.Seconds()), events)
+}
+
+// EnableWriteBuffer turns on the opt-in write buffer: once enabled, a Write
+// that fails because the connection died while a reconnect is underway
+// queues its bytes instead of blocking the caller, and reports them as
+// successfully written. The queue holds at most maxBytes; once full, Write
+// returns ErrWriteBufferFull instead of accepting more data.
+//
+// This function completely Lock()s the reconnector.
+func (r *reconnector) EnableWriteBuffer(maxBytes int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.wbuf = newWriteBuffer(maxBytes)
+}
+
+// SetOnDisconnect registers a callback invoked from Read, Write and ReadFrom
+// with the error that just took the connection down, right before a
+// reconnect is attempted.
+//
+// This function completely Lock()s the reconnector.
+func (r *reconnector) SetOnDisconnect(f func(err error)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.onDisconnect = f
+}
+
+// SetOnReconnectAttempt registers a callback invoked from Read, Write and
+// ReadFrom before each reconnect attempt, with the 1-based attempt number
+// and the backoff delay that will be used if this attempt fails.
+//
+// This function completely Lock()s the reconnector.
+func (r *reconnector) SetOnReconnectAttempt(f func(attempt int, delay time.Duration)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.onReconnectAttempt = f
+}
+
+// SetOnReconnectSuccess registers a callback invoked from reconnect with the
+// newly established net.Conn, right after it has been swapped in.
+//
+// This function completely Lock()s the reconnector.
+func (r *reconnector) SetOnReconnectSuccess(f func(conn net.Conn)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.onReconnectSuccess = f
+}
+
+// SetOnGiveUp registers a callback invoked from Read, Write and ReadFrom
+// with ErrMaxRetries right before the retry loop gives up. ReadContext and
+// WriteContext invoke it the same way when they reach the retry limit, but
+// not when they return a context error.
+//
+// This function completely Lock()s the reconnector.
+func (r *reconnector) SetOnGiveUp(f func(err error)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.onGiveUp = f
+}
+
+// SetHeartbeat turns on an idle keep-alive prober: a background goroutine
+// calls probe on the current connection every interval, and proactively
+// triggers a reconnect as soon as probe returns an error matching the
+// reconnector's isRetryable classifier, instead of waiting for the next Read
+// or Write to notice the dead connection.
+//
+// Calling SetHeartbeat again changes the interval and probe used by the
+// already-running goroutine; it does not start a second one.
+//
+// This function completely Lock()s the reconnector.
+func (r *reconnector) SetHeartbeat(interval time.Duration, probe func(conn net.Conn) error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.heartbeatInterval = interval
+	r.heartbeatProbe = probe
+	if r.heartbeatDone == nil {
+		r.heartbeatDone = make(chan struct{})
+		go r.heartbeatLoop(r.heartbeatDone)
+	}
+}
+
+// Stats returns a snapshot of the reconnector's reconnect activity: the
+// number of successful reconnects, bytes currently sitting in the write
+// buffer, the most recent error, and the backoff delay announced with the
+// most recent reconnect attempt.
+func (r *reconnector) Stats() Stats {
+	r.lock.RLock()
+	wbuf := r.wbuf
+	r.lock.RUnlock()
+
+	stats := r.stats.snapshot()
+	if wbuf != nil {
+		stats.BytesBuffered = int64(wbuf.pending())
+	}
+	return stats
+}
+
+// ----------------------------------------------------------------------------
+
+// addSockOpt records a closure that reapplies an option on a freshly dialed
+// net.Conn. Callers must hold r.lock.
+func (r *reconnector) addSockOpt(opt func(net.Conn) error) {
+	r.sockOpts = append(r.sockOpts, opt)
+}
+
+// Close closes the current connection, stopping the heartbeat goroutine
+// started by SetHeartbeat and any in-flight write-buffer flush, if any.
+func (r *reconnector) Close() error {
+	r.lock.Lock()
+	r.closeOnce.Do(func() { close(r.closed) })
+	if r.heartbeatDone != nil {
+		close(r.heartbeatDone)
+		r.heartbeatDone = nil
+	}
+	conn := r.conn
+	r.lock.Unlock()
+
+	return conn.Close()
+}
+
+// LocalAddr returns the local network address of the current connection.
+func (r *reconnector) LocalAddr() net.Addr {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address of the current connection.
+func (r *reconnector) RemoteAddr() net.Addr {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.conn.RemoteAddr()
+}
+
+// SetDeadline sets the deadline for future Read and Write calls, and ensures
+// the setting is reapplied to the connection used after a reconnect.
+func (r *reconnector) SetDeadline(t time.Time) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.conn.SetDeadline(t); err != nil {
+		return err
+	}
+	r.addSockOpt(func(conn net.Conn) error {
+		return conn.SetDeadline(t)
+	})
+	return nil
+}
+
+// SetReadDeadline sets the deadline for future Read calls, and ensures the
+// setting is reapplied to the connection used after a reconnect.
+func (r *reconnector) SetReadDeadline(t time.Time) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.conn.SetReadDeadline(t); err != nil {
+		return err
+	}
+	r.addSockOpt(func(conn net.Conn) error {
+		return conn.SetReadDeadline(t)
+	})
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for future Write calls, and ensures the
+// setting is reapplied to the connection used after a reconnect.
+func (r *reconnector) SetWriteDeadline(t time.Time) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.conn.SetWriteDeadline(t); err != nil {
+		return err
+	}
+	r.addSockOpt(func(conn net.Conn) error {
+		return conn.SetWriteDeadline(t)
+	})
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// fireOnDisconnect records err and, if set, invokes the OnDisconnect hook.
+// Callers must hold r.lock, for reading or writing.
+func (r *reconnector) fireOnDisconnect(err error) {
+	r.stats.recordError(err)
+	if r.onDisconnect != nil {
+		r.onDisconnect(err)
+	}
+}
+
+// fireOnReconnectAttempt records delay and, if set, invokes the
+// OnReconnectAttempt hook. Callers must hold r.lock, for reading or writing.
+func (r *reconnector) fireOnReconnectAttempt(attempt int, delay time.Duration) {
+	r.stats.recordBackoff(delay)
+	if r.onReconnectAttempt != nil {
+		r.onReconnectAttempt(attempt, delay)
+	}
+}
+
+// fireOnGiveUp records err and, if set, invokes the OnGiveUp hook. Callers
+// must hold r.lock, for reading or writing.
+func (r *reconnector) fireOnGiveUp(err error) {
+	r.stats.recordError(err)
+	if r.onGiveUp != nil {
+		r.onGiveUp(err)
+	}
+}
+
+// backoffLocked computes a full-jitter sleep duration for retry iteration i:
+// a random value between 0 and retryInterval * (2^i), capped at
+// maxRetryInterval when one is set. Callers must hold r.lock, for reading or
+// writing.
+func (r *reconnector) backoffLocked(i int) time.Duration {
+	upper := r.retryInterval << uint(i)
+	if r.maxRetryInterval > 0 && (upper <= 0 || upper > r.maxRetryInterval) {
+		upper = r.maxRetryInterval
+	}
+	if upper <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(upper)))
+}
+
+// ----------------------------------------------------------------------------
+
+// reconnect redials through dialer to replace the current connection.
+//
+// It is equivalent to reconnectContext with context.Background(), i.e. it
+// never aborts early for lack of a deadline.
+func (r *reconnector) reconnect() error {
+	return r.reconnectContext(context.Background())
+}
+
+// reconnectContext redials through dialer to replace the current
+// connection, aborting early if ctx is canceled before the dial completes.
+//
+// Every option previously recorded through an adapter's Set* methods is
+// replayed onto the new connection before it is swapped in, so tuning done
+// before a disconnect isn't silently lost on the next one.
+//
+// This function completely Lock()s the reconnector.
+func (r *reconnector) reconnectContext(ctx context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.reconnectLimiter != nil && !r.reconnectLimiter.Allow() {
+		return ErrReconnectRateLimited
+	}
+
+	conn, err := r.dialer.Dial(ctx)
+	if err != nil {
+		r.stats.recordError(err)
+		return err
+	}
+
+	for _, opt := range r.sockOpts {
+		if err := opt(conn); err != nil {
+			conn.Close()
+			r.stats.recordError(err)
+			return err
+		}
+	}
+
+	r.conn.Close()
+	r.conn = conn
+	if r.onConnSwap != nil {
+		r.onConnSwap(conn)
+	}
+
+	r.stats.recordReconnect()
+	if r.onReconnectSuccess != nil {
+		r.onReconnectSuccess(conn)
+	}
+	return nil
+}
+
+// reconnectAndFlush reconnects in the background, retrying until it
+// succeeds, and drains wbuf onto each newly established connection until the
+// buffer runs dry. It is the counterpart to the fast path in Write that
+// queues bytes instead of blocking on reconnect.
+func (r *reconnector) reconnectAndFlush(wbuf *writeBuffer) {
+	defer wbuf.doneFlushing()
+
+	for i := 0; wbuf.pending() > 0; {
+		select {
+		case <-r.closed:
+			return
+		default:
+		}
+
+		err := r.reconnect()
+		if err == nil {
+			i = 0
+
+			r.lock.RLock()
+			conn := r.conn
+			r.lock.RUnlock()
+
+			wbuf.drain(conn)
+			continue
+		}
+
+		// A rate-limited attempt isn't a dial failure: the limiter is
+		// already pacing redials, so don't also run the exponential
+		// backoff schedule up against it.
+		r.lock.RLock()
+		delay := r.retryInterval
+		if err != ErrReconnectRateLimited {
+			delay = r.backoffLocked(i)
+			i++
+		}
+		r.lock.RUnlock()
+
+		select {
+		case <-r.closed:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// heartbeatLoop runs probe against the current connection every
+// heartbeatInterval until done is closed by Close, reconnecting proactively
+// whenever probe reports a retryable error.
+func (r *reconnector) heartbeatLoop(done chan struct{}) {
+	for {
+		r.lock.Lock()
+		interval := r.heartbeatInterval
+		if interval <= 0 {
+			if r.heartbeatDone == done {
+				r.heartbeatDone = nil
+			}
+			r.lock.Unlock()
+			return
+		}
+		r.lock.Unlock()
+
+		select {
+		case <-done:
+			return
+		case <-time.After(interval):
+		}
+
+		r.lock.RLock()
+		conn := r.conn
+		probe := r.heartbeatProbe
+		r.lock.RUnlock()
+		if probe == nil {
+			continue
+		}
+
+		if err := probe(conn); err != nil && r.isRetryable(err) {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			r.lock.Lock()
+			r.fireOnDisconnect(err)
+			r.lock.Unlock()
+			r.reconnect()
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// Read wraps the underlying connection's Read method with reconnect
+// capabilities.
+//
+// It will return ErrMaxRetries if the retry limit is reached.
+func (r *reconnector) Read(b []byte) (int, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for i := 0; i < r.maxRetries; i++ {
+		n, err := r.conn.Read(b)
+		if err == nil {
+			return n, err
+		}
+		if !r.isRetryable(err) {
+			return n, err
+		}
+
+		r.fireOnDisconnect(err)
+		delay := r.backoffLocked(i)
+		r.fireOnReconnectAttempt(i+1, delay)
+		r.lock.RUnlock()
+		rerr := r.reconnect()
+		r.lock.RLock()
+		if rerr == ErrReconnectRateLimited {
+			return n, rerr
+		} else if rerr != nil {
+			time.Sleep(delay)
+		}
+	}
+
+	r.fireOnGiveUp(ErrMaxRetries)
+	return -1, ErrMaxRetries
+}
+
+// ReadContext is like Read, but the retry loop aborts immediately with
+// ctx.Err() as soon as ctx is canceled, instead of sleeping through the rest
+// of the backoff schedule.
+func (r *reconnector) ReadContext(ctx context.Context, b []byte) (int, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for i := 0; i < r.maxRetries; i++ {
+		if err := ctx.Err(); err != nil {
+			return -1, err
+		}
+
+		n, err := r.conn.Read(b)
+		if err == nil {
+			return n, err
+		}
+		if !r.isRetryable(err) {
+			return n, err
+		}
+
+		r.fireOnDisconnect(err)
+		delay := r.backoffLocked(i)
+		r.fireOnReconnectAttempt(i+1, delay)
+		r.lock.RUnlock()
+		rerr := r.reconnectContext(ctx)
+		r.lock.RLock()
+		if rerr == ErrReconnectRateLimited {
+			return n, rerr
+		} else if rerr != nil {
+			select {
+			case <-ctx.Done():
+				return n, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+
+	r.fireOnGiveUp(ErrMaxRetries)
+	return -1, ErrMaxRetries
+}
+
+// ReadFrom reads from src until EOF, writing everything read to the
+// underlying connection, with the same reconnect capabilities as Write.
+//
+// It will return ErrMaxRetries if the retry limit is reached.
+func (r *reconnector) ReadFrom(src io.Reader) (int64, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for i := 0; i < r.maxRetries; i++ {
+		n, err := io.Copy(r.conn, src)
+		if err == nil {
+			return n, err
+		}
+		if !r.isRetryable(err) {
+			return n, err
+		}
+
+		r.fireOnDisconnect(err)
+		delay := r.backoffLocked(i)
+		r.fireOnReconnectAttempt(i+1, delay)
+		r.lock.RUnlock()
+		rerr := r.reconnect()
+		r.lock.RLock()
+		if rerr == ErrReconnectRateLimited {
+			return n, rerr
+		} else if rerr != nil {
+			time.Sleep(delay)
+		}
+	}
+
+	r.fireOnGiveUp(ErrMaxRetries)
+	return -1, ErrMaxRetries
+}
+
+// Write wraps the underlying connection's Write method with reconnect
+// capabilities.
+//
+// It will return ErrMaxRetries if the retry limit is reached.
+//
+// If the write buffer has been enabled via EnableWriteBuffer, a Write that
+// hits a dead connection queues its bytes and reports them as written
+// instead of blocking on reconnect; it returns ErrWriteBufferFull if the
+// queue has no room left.
+func (r *reconnector) Write(b []byte) (int, error) {
+	r.lock.RLock()
+	wbuf := r.wbuf
+	r.lock.RUnlock()
+
+	// A flush is already draining queued bytes onto a fresh connection;
+	// queue behind it instead of racing the flusher for the live conn.
+	if wbuf != nil && wbuf.pending() > 0 {
+		if err := wbuf.push(b); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for i := 0; i < r.maxRetries; i++ {
+		n, err := r.conn.Write(b)
+		if err == nil {
+			return n, err
+		}
+		if !r.isRetryable(err) {
+			return n, err
+		}
+
+		r.fireOnDisconnect(err)
+		if wbuf != nil {
+			if err := wbuf.push(b); err != nil {
+				return 0, err
+			}
+			if wbuf.startFlushing() {
+				go r.reconnectAndFlush(wbuf)
+			}
+			return len(b), nil
+		}
+
+		delay := r.backoffLocked(i)
+		r.fireOnReconnectAttempt(i+1, delay)
+		r.lock.RUnlock()
+		rerr := r.reconnect()
+		r.lock.RLock()
+		if rerr == ErrReconnectRateLimited {
+			return n, rerr
+		} else if rerr != nil {
+			time.Sleep(delay)
+		}
+	}
+
+	r.fireOnGiveUp(ErrMaxRetries)
+	return -1, ErrMaxRetries
+}
+
+// WriteContext is like Write, but the retry loop aborts immediately with
+// ctx.Err() as soon as ctx is canceled, instead of sleeping through the rest
+// of the backoff schedule. The write buffer fast path, if enabled, still
+// applies and takes priority over waiting on ctx.
+func (r *reconnector) WriteContext(ctx context.Context, b []byte) (int, error) {
+	r.lock.RLock()
+	wbuf := r.wbuf
+	r.lock.RUnlock()
+
+	if wbuf != nil && wbuf.pending() > 0 {
+		if err := wbuf.push(b); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for i := 0; i < r.maxRetries; i++ {
+		if err := ctx.Err(); err != nil {
+			return -1, err
+		}
+
+		n, err := r.conn.Write(b)
+		if err == nil {
+			return n, err
+		}
+		if !r.isRetryable(err) {
+			return n, err
+		}
+
+		r.fireOnDisconnect(err)
+		if wbuf != nil {
+			if err := wbuf.push(b); err != nil {
+				return 0, err
+			}
+			if wbuf.startFlushing() {
+				go r.reconnectAndFlush(wbuf)
+			}
+			return len(b), nil
+		}
+
+		delay := r.backoffLocked(i)
+		r.fireOnReconnectAttempt(i+1, delay)
+		r.lock.RUnlock()
+		rerr := r.reconnectContext(ctx)
+		r.lock.RLock()
+		if rerr == ErrReconnectRateLimited {
+			return n, rerr
+		} else if rerr != nil {
+			select {
+			case <-ctx.Done():
+				return n, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+
+	r.fireOnGiveUp(ErrMaxRetries)
+	return -1, ErrMaxRetries
+}
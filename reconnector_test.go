@@ -0,0 +1,179 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+
+func TestReconnector_SetHeartbeat(t *testing.T) {
+	c, err := Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	probed := make(chan struct{}, 1)
+	failing := true
+	c.(*TCPClient).SetHeartbeat(10*time.Millisecond, func(conn net.Conn) error {
+		select {
+		case probed <- struct{}{}:
+		default:
+		}
+		if failing {
+			failing = false
+			return errors.New("EOF")
+		}
+		return nil
+	})
+
+	select {
+	case <-probed:
+	case <-time.After(time.Second):
+		t.Fatal("heartbeat probe was never called")
+	}
+
+	tcpConn1 := c.(*TCPClient).TCPConn()
+	time.Sleep(100 * time.Millisecond)
+	tcpConn2 := c.(*TCPClient).TCPConn()
+	if tcpConn2 == nil || tcpConn1 == tcpConn2 {
+		t.Error("heartbeat failure did not trigger a reconnect")
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+func TestReconnector_SetReconnectRateLimit(t *testing.T) {
+	c, err := Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tc := c.(*TCPClient)
+	tc.SetReconnectRateLimit(1, time.Hour)
+
+	if err := tc.reconnect(); err != nil {
+		t.Fatalf("first reconnect should succeed, got %v", err)
+	}
+	if err := tc.reconnect(); err != ErrReconnectRateLimited {
+		t.Errorf("got %v, want ErrReconnectRateLimited once the limit is exhausted", err)
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+func TestReconnector_SetMaxRetryInterval(t *testing.T) {
+	s, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := s.Addr()
+
+	c, err := Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tc := c.(*TCPClient)
+	tc.SetMaxRetries(20)
+	tc.SetRetryInterval(5 * time.Millisecond)
+	tc.SetMaxRetryInterval(20 * time.Millisecond)
+
+	var maxSeen time.Duration
+	tc.SetOnReconnectAttempt(func(attempt int, delay time.Duration) {
+		if delay > maxSeen {
+			maxSeen = delay
+		}
+	})
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Write([]byte("x")); err != ErrMaxRetries {
+		t.Fatalf("got %v, want ErrMaxRetries against a dead server", err)
+	}
+	if maxSeen > 20*time.Millisecond {
+		t.Errorf("observed backoff %v, want capped at the 20ms SetMaxRetryInterval", maxSeen)
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+func TestTCPClient_ReadContext(t *testing.T) {
+	s, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := s.Addr()
+
+	c, err := Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tc := c.(*TCPClient)
+	tc.SetMaxRetries(50)
+	tc.SetRetryInterval(time.Second)
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	b := make([]byte, 1)
+	if _, err := tc.ReadContext(ctx, b); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ReadContext took %v to abort, want well under the 1s SetRetryInterval", elapsed)
+	}
+}
+
+func TestTCPClient_WriteContext(t *testing.T) {
+	s, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := s.Addr()
+
+	c, err := Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tc := c.(*TCPClient)
+	tc.SetMaxRetries(50)
+	tc.SetRetryInterval(time.Second)
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := tc.WriteContext(ctx, []byte("x")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WriteContext took %v to abort, want well under the 1s SetRetryInterval", elapsed)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+
+// Stats is a point-in-time snapshot of a TCPClient's reconnect activity. It
+// is meant to be polled and wired into Prometheus/expvar without having to
+// instrument every call site.
+type Stats struct {
+	// Reconnects is the number of times the client has successfully
+	// redialed the remote peer.
+	Reconnects int64
+
+	// BytesBuffered is the number of bytes currently sitting in the write
+	// buffer, waiting to be flushed to a live connection. It is always zero
+	// if EnableWriteBuffer was never called.
+	BytesBuffered int64
+
+	// LastError is the most recent error observed by Read, Write, ReadFrom
+	// or reconnect, or nil if none has occurred yet.
+	LastError error
+
+	// CurrentBackoff is the backoff delay announced with the most recent
+	// reconnect attempt, i.e. the delay slept after that attempt if it fails.
+	CurrentBackoff time.Duration
+}
+
+// clientStats holds the atomic counters backing TCPClient.Stats.
+type clientStats struct {
+	reconnects     int64
+	currentBackoff int64 // time.Duration, accessed atomically
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+func (s *clientStats) recordReconnect() {
+	atomic.AddInt64(&s.reconnects, 1)
+}
+
+func (s *clientStats) recordBackoff(d time.Duration) {
+	atomic.StoreInt64(&s.currentBackoff, int64(d))
+}
+
+func (s *clientStats) recordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastErr = err
+}
+
+func (s *clientStats) snapshot() Stats {
+	s.mu.Lock()
+	lastErr := s.lastErr
+	s.mu.Unlock()
+
+	return Stats{
+		Reconnects:     atomic.LoadInt64(&s.reconnects),
+		LastError:      lastErr,
+		CurrentBackoff: time.Duration(atomic.LoadInt64(&s.currentBackoff)),
+	}
+}
@@ -6,9 +6,11 @@
 package gas
 
 import (
-	"io"
+	"context"
+	"errors"
+	"fmt"
 	"net"
-	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -17,19 +19,81 @@ import (
 
 // TCPClient provides a TCP connection with auto-reconnect capabilities.
 //
-// It embeds a *net.TCPConn and thus implements the net.Conn interface.
+// It embeds a *reconnector and thus implements the net.Conn interface, along
+// with the retry/backoff, rate-limiting, write-buffering, lifecycle-hook and
+// Stats machinery documented on reconnector.
 //
 // Use the SetMaxRetries() and SetRetryInterval() methods to configure retry
 // values; otherwise they default to maxRetries=5 and retryInterval=100ms.
 //
 // TCPClient can be safely used from multiple goroutines.
 type TCPClient struct {
-	*net.TCPConn
+	*reconnector
+
+	// tcpConn mirrors the *net.TCPConn currently held by the embedded
+	// reconnector, and is kept in sync across every reconnect. It is stored
+	// behind an atomic pointer, not a plain field, so TCPConn can be called
+	// concurrently with a reconnect (e.g. from a SetHeartbeat probe) without
+	// racing reconnectContext's write to it.
+	tcpConn atomic.Pointer[net.TCPConn]
+}
+
+// TCPConn returns the *net.TCPConn currently held by the embedded
+// reconnector. It is provided for callers that need TCP-specific
+// functionality beyond the net.Conn interface, and is safe to call from any
+// goroutine, including concurrently with a reconnect.
+func (c *TCPClient) TCPConn() *net.TCPConn {
+	return c.tcpConn.Load()
+}
+
+// tcpDialer redials the same TCP remote a TCPClient was originally pointed
+// at, on every reconnect.
+type tcpDialer struct {
+	network      string
+	laddr, raddr *net.TCPAddr
+}
+
+// Dial implements Dialer.
+func (d tcpDialer) Dial(ctx context.Context) (net.Conn, error) {
+	var nd net.Dialer
+	if d.laddr != nil {
+		// A typed-nil *net.TCPAddr stored in the Dialer.LocalAddr interface
+		// would no longer compare equal to nil, so only set it when present.
+		nd.LocalAddr = d.laddr
+	}
+	conn, err := nd.DialContext(ctx, d.network, d.raddr.String())
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := conn.(*net.TCPConn); !ok {
+		conn.Close()
+		return nil, fmt.Errorf("gas: %s dial did not return a *net.TCPConn", d.network)
+	}
+	return conn, nil
+}
 
-	lock sync.RWMutex
+// isRetryableSyscallError reports whether err, as returned by a Read or
+// Write on a TCP or Unix socket, indicates the connection has died and is
+// worth redialing. It is shared by TCPClient and UnixClient.
+func isRetryableSyscallError(err error) bool {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return errno == syscall.EPIPE || errno == syscall.ECONNRESET
+	}
+	return err.Error() == "EOF"
+}
 
-	maxRetries    int
-	retryInterval time.Duration
+// newTCPClient wraps conn with the reconnect machinery, redialing through
+// dialer, and keeps TCPConn in sync across reconnects.
+func newTCPClient(conn *net.TCPConn, dialer Dialer) *TCPClient {
+	c := &TCPClient{
+		reconnector: newReconnector(conn, dialer, isRetryableSyscallError),
+	}
+	c.tcpConn.Store(conn)
+	c.reconnector.onConnSwap = func(conn net.Conn) {
+		c.tcpConn.Store(conn.(*net.TCPConn))
+	}
+	return c
 }
 
 // Dial returns a new net.Conn.
@@ -60,206 +124,145 @@ func DialTCP(network string, laddr, raddr *net.TCPAddr) (*TCPClient, error) {
 		return nil, err
 	}
 
-	return &TCPClient{
-		TCPConn: conn,
+	return newTCPClient(conn, tcpDialer{network: network, laddr: laddr, raddr: raddr}), nil
+}
 
-		lock: sync.RWMutex{},
+// DialContext is like Dial but uses ctx to bound the initial connection
+// attempt; it is canceled the same way net.Dialer.DialContext would cancel
+// it.
+func DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	raddr, err := net.ResolveTCPAddr(network, addr)
+	if err != nil {
+		return nil, err
+	}
 
-		maxRetries:    5,
-		retryInterval: 100 * time.Millisecond,
-	}, nil
+	return DialTCPContext(ctx, network, nil, raddr)
 }
 
-// ----------------------------------------------------------------------------
-
-// SetMaxRetries sets the retry limit for the TCPClient.
-//
-// Assuming i is the current retry iteration, the total sleep time is
-// t = retryInterval * (2^i)
-//
-// This function completely Lock()s the TCPClient.
-func (c *TCPClient) SetMaxRetries(maxRetries int) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+// DialTCPContext is like DialTCP but uses ctx to bound the initial connection
+// attempt; it is canceled the same way net.Dialer.DialContext would cancel
+// it.
+func DialTCPContext(ctx context.Context, network string, laddr, raddr *net.TCPAddr) (*TCPClient, error) {
+	d := tcpDialer{network: network, laddr: laddr, raddr: raddr}
+	conn, err := d.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	c.maxRetries = maxTries
+	return newTCPClient(conn.(*net.TCPConn), d), nil
 }
 
-// GetMaxRetries gets the retry limit for the TCPClient.
-//
-// Assuming i is the current retry iteration, the total sleep time is
-// t = retryInterval * (2^i)
-func (c *TCPClient) GetMaxRetries(maxRetries int) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+// ----------------------------------------------------------------------------
 
-	return c.maxRetries
+// addSockOpt records a closure that reapplies an option on a freshly dialed
+// *net.TCPConn. Callers must hold c.lock.
+func (c *TCPClient) addSockOpt(opt func(*net.TCPConn) error) {
+	c.reconnector.addSockOpt(func(conn net.Conn) error {
+		return opt(conn.(*net.TCPConn))
+	})
 }
 
-// SetRetryInterval sets the retry interval for the TCPClient.
-//
-// Assuming i is the current retry iteration, the total sleep time is
-// t = retryInterval * (2^i)
-//
-// This function completely Lock()s the TCPClient.
-func (c *TCPClient) SetRetryInterval(retryInterval time.Duration) {
+// SetKeepAlive sets whether the operating system should send keepalive
+// messages on the connection, and ensures the setting survives reconnects.
+func (c *TCPClient) SetKeepAlive(keepalive bool) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	c.retryInterval = retryInterval
+	if err := c.TCPConn().SetKeepAlive(keepalive); err != nil {
+		return err
+	}
+	c.addSockOpt(func(conn *net.TCPConn) error {
+		return conn.SetKeepAlive(keepalive)
+	})
+	return nil
 }
 
-// GetRetryInterval gets the retry interval for the TCPClient.
-//
-// Assuming i is the current retry iteration, the total sleep time is
-// t = retryInterval * (2^i)
-func (c *TCPClient) GetRetryInterval(retryInterval time.Duration) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+// SetKeepAlivePeriod sets the interval between keepalive messages, and
+// ensures the setting survives reconnects.
+func (c *TCPClient) SetKeepAlivePeriod(d time.Duration) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-	return c.retryInterval
+	if err := c.TCPConn().SetKeepAlivePeriod(d); err != nil {
+		return err
+	}
+	c.addSockOpt(func(conn *net.TCPConn) error {
+		return conn.SetKeepAlivePeriod(d)
+	})
+	return nil
 }
 
-// ----------------------------------------------------------------------------
-
-// reconnect builds a new TCP connection to replace the embedded *net.TCPConn.
-//
-// This function completely Lock()s the TCPClient.
-//
-// TODO: keep old socket configuration (timeout, linger...).
-func (c *TCPClient) reconnect() error {
+// SetLinger sets the behavior of Close() on a connection that still has data
+// waiting to be sent or to be acknowledged, and ensures the setting survives
+// reconnects.
+func (c *TCPClient) SetLinger(sec int) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	raddr := c.TCPConn.RemoteAddr()
-	conn, err := net.DialTCP(raddr.Network(), nil, raddr.(*net.TCPAddr))
-	if err != nil {
+	if err := c.TCPConn().SetLinger(sec); err != nil {
 		return err
 	}
-
-	c.TCPConn.Close()
-	c.TCPConn = conn
+	c.addSockOpt(func(conn *net.TCPConn) error {
+		return conn.SetLinger(sec)
+	})
 	return nil
 }
 
-// ----------------------------------------------------------------------------
+// SetNoDelay controls whether the operating system should delay packet
+// transmission in hopes of sending fewer packets (Nagle's algorithm), and
+// ensures the setting survives reconnects.
+func (c *TCPClient) SetNoDelay(noDelay bool) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-// Read wraps net.TCPConn's Read method with reconnect capabilities.
-//
-// It will return ErrMaxRetries if the retry limit is reached.
-func (c *TCPClient) Read(b []byte) (int, error) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-
-	maxTries := 5
-	t := time.Millisecond * 100
-
-	for i := 0; i < maxTries; i++ {
-		n, err := c.TCPConn.Read(b)
-		if err == nil {
-			return n, err
-		}
-		switch e := err.(type) {
-		case *net.OpError:
-			if e.Err.(syscall.Errno) == syscall.EPIPE ||
-				e.Err.(syscall.Errno) == syscall.ECONNRESET {
-				c.lock.RUnlock()
-				if c.reconnect() != nil {
-					time.Sleep(t)
-				}
-				c.lock.RLock()
-			}
-		default:
-			if err.Error() == "EOF" {
-				c.lock.RUnlock()
-				if c.reconnect() != nil {
-					time.Sleep(t)
-				}
-				c.lock.RLock()
-			} else {
-				return n, err
-			}
-		}
-		t *= 2
+	if err := c.TCPConn().SetNoDelay(noDelay); err != nil {
+		return err
 	}
-
-	return -1, ErrMaxRetries
+	c.addSockOpt(func(conn *net.TCPConn) error {
+		return conn.SetNoDelay(noDelay)
+	})
+	return nil
 }
 
-// ReadFrom wraps net.TCPConn's Read method with reconnect capabilities.
-//
-// It will return ErrMaxRetries if the retry limit is reached.
-func (c *TCPClient) ReadFrom(r io.Reader) (int64, error) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-
-	maxTries := 5
-	t := time.Millisecond * 100
-
-	for i := 0; i < maxTries; i++ {
-		n, err := c.TCPConn.ReadFrom(r)
-		if err == nil {
-			return n, err
-		}
-		switch e := err.(type) {
-		case *net.OpError:
-			if e.Err.(syscall.Errno) == syscall.EPIPE ||
-				e.Err.(syscall.Errno) == syscall.ECONNRESET {
-				c.lock.RUnlock()
-				if c.reconnect() != nil {
-					time.Sleep(t)
-				}
-				c.lock.RLock()
-			}
-		default:
-			if err.Error() == "EOF" {
-				c.lock.RUnlock()
-				if c.reconnect() != nil {
-					time.Sleep(t)
-				}
-				c.lock.RLock()
-			} else {
-				return n, err
-			}
-		}
-		t *= 2
-	}
+// SetReadBuffer sets the size of the operating system's receive buffer
+// associated with the connection, and ensures the setting survives
+// reconnects.
+func (c *TCPClient) SetReadBuffer(bytes int) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-	return -1, ErrMaxRetries
+	if err := c.TCPConn().SetReadBuffer(bytes); err != nil {
+		return err
+	}
+	c.addSockOpt(func(conn *net.TCPConn) error {
+		return conn.SetReadBuffer(bytes)
+	})
+	return nil
 }
 
-// Write wraps net.TCPConn's Read method with reconnect capabilities.
-//
-// It will return ErrMaxRetries if the retry limit is reached.
-func (c *TCPClient) Write(b []byte) (int, error) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-
-	maxTries := 5
-	t := time.Millisecond * 100
-
-	for i := 0; i < maxTries; i++ {
-		n, err := c.TCPConn.Write(b)
-		if err == nil {
-			return n, err
-		}
-		switch e := err.(type) {
-		case *net.OpError:
-			if e.Err.(syscall.Errno) == syscall.EPIPE ||
-				e.Err.(syscall.Errno) == syscall.ECONNRESET {
-				c.lock.RUnlock()
-				if c.reconnect() != nil {
-					time.Sleep(t)
-				}
-				c.lock.RLock()
-			} else {
-				return n, err
-			}
-		default:
-			return n, err
-		}
-		t *= 2
+// SetWriteBuffer sets the size of the operating system's transmit buffer
+// associated with the connection, and ensures the setting survives
+// reconnects.
+func (c *TCPClient) SetWriteBuffer(bytes int) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.TCPConn().SetWriteBuffer(bytes); err != nil {
+		return err
 	}
+	c.addSockOpt(func(conn *net.TCPConn) error {
+		return conn.SetWriteBuffer(bytes)
+	})
+	return nil
+}
 
-	return -1, ErrMaxRetries
+// SetTCPKeepAlivePeriod is a convenience for callers who don't need a custom
+// SetHeartbeat probe: it turns on the operating system's native TCP
+// keepalive and sets its period, surviving reconnects the same way
+// SetKeepAlive and SetKeepAlivePeriod do.
+func (c *TCPClient) SetTCPKeepAlivePeriod(d time.Duration) error {
+	if err := c.SetKeepAlive(true); err != nil {
+		return err
+	}
+	return c.SetKeepAlivePeriod(d)
 }
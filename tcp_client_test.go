@@ -6,6 +6,8 @@
 package gas
 
 import (
+	"context"
+	"errors"
 	"log"
 	"math/rand"
 	"net"
@@ -46,7 +48,7 @@ func TestTCPClient_Dial(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if c == nil || c.(*TCPClient).TCPConn == nil {
+	if c == nil || c.(*TCPClient).TCPConn() == nil {
 		t.Error("initialization failed")
 	}
 	if err := c.Close(); err != nil {
@@ -59,7 +61,7 @@ func TestTCPClient_DialTCP(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if c == nil || c.TCPConn == nil {
+	if c == nil || c.TCPConn() == nil {
 		t.Error("initialization failed")
 	}
 	if err := c.Close(); err != nil {
@@ -69,22 +71,62 @@ func TestTCPClient_DialTCP(t *testing.T) {
 
 // ----------------------------------------------------------------------------
 
+func TestTCPClient_DialContext(t *testing.T) {
+	c, err := DialContext(context.Background(), "tcp", server.Addr().String())
+	if err != nil {
+		t.Error(err)
+	}
+	if c == nil || c.(*TCPClient).TCPConn() == nil {
+		t.Error("initialization failed")
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DialContext(ctx, "tcp", server.Addr().String()); err == nil {
+		t.Error("DialContext should have failed against an already-canceled context")
+	}
+}
+
+func TestTCPClient_DialTCPContext(t *testing.T) {
+	c, err := DialTCPContext(context.Background(), "tcp", nil, server.Addr().(*net.TCPAddr))
+	if err != nil {
+		t.Error(err)
+	}
+	if c == nil || c.TCPConn() == nil {
+		t.Error("initialization failed")
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DialTCPContext(ctx, "tcp", nil, server.Addr().(*net.TCPAddr)); err == nil {
+		t.Error("DialTCPContext should have failed against an already-canceled context")
+	}
+}
+
+// ----------------------------------------------------------------------------
+
 func TestTCPClient_reconnect(t *testing.T) {
 	c, _ := Dial("tcp", server.Addr().String())
 	defer c.Close()
 
-	tcpConn1 := c.(*TCPClient).TCPConn
+	tcpConn1 := c.(*TCPClient).TCPConn()
 	if err := c.(*TCPClient).reconnect(); err != nil {
 		t.Error(err)
 	}
-	tcpConn2 := c.(*TCPClient).TCPConn
+	tcpConn2 := c.(*TCPClient).TCPConn()
 	if tcpConn2 == nil || tcpConn1 == tcpConn2 {
 		t.Error("reconnection failed")
 	}
 
 	if err := tcpConn1.Close(); err == nil {
 		t.Error("tcpConn1 should already be closed")
-	} else if err.Error() != "use of closed network connection" {
+	} else if !errors.Is(err, net.ErrClosed) {
 		t.Error(err)
 	}
 	if err := tcpConn2.Close(); err != nil {
@@ -0,0 +1,241 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+
+// TCPServer provides a TCP listener with auto-rebind capabilities.
+//
+// It embeds a *net.TCPListener and thus implements the net.Listener interface.
+//
+// Use the SetMaxRetries() and SetRetryInterval() methods to configure retry
+// values; otherwise they default to maxRetries=5 and retryInterval=100ms.
+//
+// TCPServer can be safely used from multiple goroutines.
+type TCPServer struct {
+	*net.TCPListener
+
+	lock sync.RWMutex
+
+	addr *net.TCPAddr
+
+	maxRetries    int
+	retryInterval time.Duration
+
+	// sockOpts replays every socket option a caller has set, in order, onto
+	// every *net.TCPConn handed back by Accept.
+	sockOpts []func(*net.TCPConn) error
+}
+
+// ListenTCP returns a new *TCPServer.
+//
+// The new server listens on the local address `addr` on the network
+// `network`, which must be "tcp", "tcp4", or "tcp6".
+func ListenTCP(network, addr string) (*TCPServer, error) {
+	laddr, err := net.ResolveTCPAddr(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := net.ListenTCP(network, laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TCPServer{
+		TCPListener: l,
+
+		lock: sync.RWMutex{},
+
+		addr: laddr,
+
+		maxRetries:    5,
+		retryInterval: 100 * time.Millisecond,
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// SetMaxRetries sets the rebind retry limit for the TCPServer.
+//
+// Assuming i is the current retry iteration, the total sleep time is
+// t = retryInterval * (2^i)
+//
+// This function completely Lock()s the TCPServer.
+func (s *TCPServer) SetMaxRetries(maxRetries int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.maxRetries = maxRetries
+}
+
+// GetMaxRetries gets the rebind retry limit for the TCPServer.
+//
+// Assuming i is the current retry iteration, the total sleep time is
+// t = retryInterval * (2^i)
+func (s *TCPServer) GetMaxRetries() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.maxRetries
+}
+
+// SetRetryInterval sets the rebind retry interval for the TCPServer.
+//
+// Assuming i is the current retry iteration, the total sleep time is
+// t = retryInterval * (2^i)
+//
+// This function completely Lock()s the TCPServer.
+func (s *TCPServer) SetRetryInterval(retryInterval time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.retryInterval = retryInterval
+}
+
+// GetRetryInterval gets the rebind retry interval for the TCPServer.
+//
+// Assuming i is the current retry iteration, the total sleep time is
+// t = retryInterval * (2^i)
+func (s *TCPServer) GetRetryInterval() time.Duration {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.retryInterval
+}
+
+// ----------------------------------------------------------------------------
+
+// addSockOpt records a closure that applies a socket option to every
+// *net.TCPConn returned by Accept. Callers must hold s.lock.
+func (s *TCPServer) addSockOpt(opt func(*net.TCPConn) error) {
+	s.sockOpts = append(s.sockOpts, opt)
+}
+
+// SetKeepAlive arranges for every future accepted connection to have
+// keepalive messages enabled or disabled as specified.
+func (s *TCPServer) SetKeepAlive(keepalive bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.addSockOpt(func(conn *net.TCPConn) error {
+		return conn.SetKeepAlive(keepalive)
+	})
+}
+
+// SetReadBuffer arranges for every future accepted connection to have its
+// receive buffer sized as specified.
+func (s *TCPServer) SetReadBuffer(bytes int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.addSockOpt(func(conn *net.TCPConn) error {
+		return conn.SetReadBuffer(bytes)
+	})
+}
+
+// SetWriteBuffer arranges for every future accepted connection to have its
+// transmit buffer sized as specified.
+func (s *TCPServer) SetWriteBuffer(bytes int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.addSockOpt(func(conn *net.TCPConn) error {
+		return conn.SetWriteBuffer(bytes)
+	})
+}
+
+// SetDeadline arranges for every future accepted connection to have its
+// read and write deadline set as specified.
+func (s *TCPServer) SetDeadline(t time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.addSockOpt(func(conn *net.TCPConn) error {
+		return conn.SetDeadline(t)
+	})
+}
+
+// ----------------------------------------------------------------------------
+
+// rebind rebuilds the TCP listener on the server's original address, to
+// replace one that AcceptTCP reported dead.
+//
+// This function completely Lock()s the TCPServer.
+func (s *TCPServer) rebind() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	l, err := net.ListenTCP(s.addr.Network(), s.addr)
+	if err != nil {
+		return err
+	}
+
+	s.TCPListener.Close()
+	s.TCPListener = l
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Accept wraps net.TCPListener's AcceptTCP method with auto-rebind
+// capabilities: if the underlying socket was closed out from under it (as
+// simulated in TestTCPClient_Write), the server rebinds to its original
+// address instead of handing the caller a fatal error. Every socket option
+// registered through the server's Set* methods is applied to the accepted
+// connection before it is returned.
+//
+// It will return ErrMaxRetries if the retry limit is reached.
+func (s *TCPServer) Accept() (net.Conn, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	t := s.retryInterval
+
+	for i := 0; i < s.maxRetries; i++ {
+		conn, err := s.TCPListener.AcceptTCP()
+		if err == nil {
+			for _, opt := range s.sockOpts {
+				if err := opt(conn); err != nil {
+					conn.Close()
+					return nil, err
+				}
+			}
+			return conn, nil
+		}
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil, err
+		}
+
+		s.lock.RUnlock()
+		if s.rebind() != nil {
+			time.Sleep(t)
+		}
+		s.lock.RLock()
+		t *= 2
+	}
+
+	return nil, ErrMaxRetries
+}
+
+// Serve accepts connections in a loop and hands each one to handler in its
+// own goroutine, until Accept gives up with ErrMaxRetries or returns a
+// non-retryable error, which Serve then returns.
+func (s *TCPServer) Serve(handler func(net.Conn)) error {
+	for {
+		conn, err := s.Accept()
+		if err != nil {
+			return err
+		}
+		go handler(conn)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// ----------------------------------------------------------------------------
+
+func TestTCPServer_ListenTCP(t *testing.T) {
+	s, err := ListenTCP("tcp", "localhost:0")
+	if err != nil {
+		t.Error(err)
+	}
+	if s == nil || s.TCPListener == nil {
+		t.Error("initialization failed")
+	}
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+func TestTCPServer_rebind(t *testing.T) {
+	s, err := ListenTCP("tcp", "localhost:0")
+	if err != nil {
+		t.Error(err)
+	}
+	defer s.Close()
+
+	listener1 := s.TCPListener
+	if err := s.rebind(); err != nil {
+		t.Error(err)
+	}
+	listener2 := s.TCPListener
+	if listener2 == nil || listener1 == listener2 {
+		t.Error("rebind failed")
+	}
+
+	if err := listener1.Close(); err == nil {
+		t.Error("listener1 should already be closed")
+	} else if !errors.Is(err, net.ErrClosed) {
+		t.Error(err)
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+func TestTCPServer_Accept(t *testing.T) {
+	s, err := ListenTCP("tcp", "localhost:0")
+	if err != nil {
+		t.Error(err)
+	}
+	defer s.Close()
+
+	s.SetKeepAlive(true)
+
+	go func() {
+		c, err := Dial("tcp", s.Addr().String())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer c.Close()
+	}()
+
+	conn, err := s.Accept()
+	if err != nil {
+		t.Error(err)
+	}
+	if conn == nil {
+		t.Error("accept failed")
+	} else {
+		defer conn.Close()
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+var _ net.Listener = (*TCPServer)(nil)
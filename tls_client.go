@@ -0,0 +1,89 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+)
+
+// ----------------------------------------------------------------------------
+
+// TLSClient provides a TLS connection with auto-reconnect capabilities.
+//
+// It embeds a *reconnector and thus implements the net.Conn interface, along
+// with the retry/backoff, rate-limiting, write-buffering, lifecycle-hook and
+// Stats machinery documented on reconnector. Every reconnect redials the
+// remote from scratch and redoes the TLS handshake.
+//
+// TLSClient can be safely used from multiple goroutines.
+type TLSClient struct {
+	*reconnector
+
+	// tlsConn mirrors the *tls.Conn currently held by the embedded
+	// reconnector, and is kept in sync across every reconnect. It is stored
+	// behind an atomic pointer, not a plain field, so Conn can be called
+	// concurrently with a reconnect without racing reconnectContext's write
+	// to it.
+	tlsConn atomic.Pointer[tls.Conn]
+}
+
+// Conn returns the *tls.Conn currently held by the embedded reconnector. It
+// is safe to call from any goroutine, including concurrently with a
+// reconnect.
+func (c *TLSClient) Conn() *tls.Conn {
+	return c.tlsConn.Load()
+}
+
+// tlsDialer redials the same TLS remote a TLSClient was originally pointed
+// at, handshaking again on every reconnect.
+type tlsDialer struct {
+	network string
+	addr    string
+	config  *tls.Config
+}
+
+// Dial implements Dialer.
+func (d tlsDialer) Dial(ctx context.Context) (net.Conn, error) {
+	td := tls.Dialer{Config: d.config}
+	return td.DialContext(ctx, d.network, d.addr)
+}
+
+// newTLSClient wraps conn with the reconnect machinery, redialing and
+// re-handshaking through dialer, and keeps Conn in sync across reconnects.
+func newTLSClient(conn *tls.Conn, dialer Dialer) *TLSClient {
+	c := &TLSClient{
+		reconnector: newReconnector(conn, dialer, isRetryableSyscallError),
+	}
+	c.tlsConn.Store(conn)
+	c.reconnector.onConnSwap = func(conn net.Conn) {
+		c.tlsConn.Store(conn.(*tls.Conn))
+	}
+	return c
+}
+
+// DialTLS returns a new *TLSClient.
+//
+// The new client connects to the remote address `addr` on the network
+// `network`, which must be "tcp", "tcp4", or "tcp6", and performs a TLS
+// handshake using config.
+func DialTLS(network, addr string, config *tls.Config) (*TLSClient, error) {
+	return DialTLSContext(context.Background(), network, addr, config)
+}
+
+// DialTLSContext is like DialTLS but uses ctx to bound the initial
+// connection attempt and handshake.
+func DialTLSContext(ctx context.Context, network, addr string, config *tls.Config) (*TLSClient, error) {
+	d := tlsDialer{network: network, addr: addr, config: config}
+	conn, err := d.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTLSClient(conn.(*tls.Conn), d), nil
+}
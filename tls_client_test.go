@@ -0,0 +1,124 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+
+// selfSignedTLSConfigs generates an in-memory certificate and returns a
+// server-side and a client-side tls.Config built around it, for use in
+// tests that need a TLS listener without touching the filesystem.
+func selfSignedTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	tlsCert := tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+
+	server = &tls.Config{Certificates: []tls.Certificate{tlsCert}}
+	client = &tls.Config{RootCAs: pool, ServerName: "localhost"}
+	return server, client
+}
+
+func TestTLSClient_DialTLS(t *testing.T) {
+	serverConfig, clientConfig := selfSignedTLSConfigs(t)
+
+	l, err := tls.Listen("tcp", "localhost:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	c, err := DialTLS("tcp", l.Addr().String(), clientConfig)
+	if err != nil {
+		t.Error(err)
+	}
+	if c == nil || c.Conn() == nil {
+		t.Error("initialization failed")
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTLSClient_reconnect(t *testing.T) {
+	serverConfig, clientConfig := selfSignedTLSConfigs(t)
+
+	l, err := tls.Listen("tcp", "localhost:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	c, err := DialTLS("tcp", l.Addr().String(), clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	conn1 := c.Conn()
+	if err := c.reconnect(); err != nil {
+		t.Error(err)
+	}
+	conn2 := c.Conn()
+	if conn2 == nil || conn1 == conn2 {
+		t.Error("reconnection failed")
+	}
+}
+
+var _ net.Conn = (*TLSClient)(nil)
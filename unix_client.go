@@ -0,0 +1,110 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+// ----------------------------------------------------------------------------
+
+// UnixClient provides a Unix domain socket connection with auto-reconnect
+// capabilities.
+//
+// It embeds a *reconnector and thus implements the net.Conn interface, along
+// with the retry/backoff, rate-limiting, write-buffering, lifecycle-hook and
+// Stats machinery documented on reconnector.
+//
+// UnixClient can be safely used from multiple goroutines.
+type UnixClient struct {
+	*reconnector
+
+	// unixConn mirrors the *net.UnixConn currently held by the embedded
+	// reconnector, and is kept in sync across every reconnect. It is stored
+	// behind an atomic pointer, not a plain field, so UnixConn can be called
+	// concurrently with a reconnect without racing reconnectContext's write
+	// to it.
+	unixConn atomic.Pointer[net.UnixConn]
+}
+
+// UnixConn returns the *net.UnixConn currently held by the embedded
+// reconnector. It is safe to call from any goroutine, including
+// concurrently with a reconnect.
+func (c *UnixClient) UnixConn() *net.UnixConn {
+	return c.unixConn.Load()
+}
+
+// unixDialer redials the same Unix domain socket a UnixClient was originally
+// pointed at, on every reconnect.
+type unixDialer struct {
+	network      string
+	laddr, raddr *net.UnixAddr
+}
+
+// Dial implements Dialer.
+func (d unixDialer) Dial(ctx context.Context) (net.Conn, error) {
+	var nd net.Dialer
+	if d.laddr != nil {
+		// A typed-nil *net.UnixAddr stored in the Dialer.LocalAddr interface
+		// is non-nil and gets dereferenced by DialContext, so only set it
+		// when present.
+		nd.LocalAddr = d.laddr
+	}
+	conn, err := nd.DialContext(ctx, d.network, d.raddr.String())
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := conn.(*net.UnixConn); !ok {
+		conn.Close()
+		return nil, fmt.Errorf("gas: %s dial did not return a *net.UnixConn", d.network)
+	}
+	return conn, nil
+}
+
+// newUnixClient wraps conn with the reconnect machinery, redialing through
+// dialer, and keeps UnixConn in sync across reconnects.
+func newUnixClient(conn *net.UnixConn, dialer Dialer) *UnixClient {
+	c := &UnixClient{
+		reconnector: newReconnector(conn, dialer, isRetryableSyscallError),
+	}
+	c.unixConn.Store(conn)
+	c.reconnector.onConnSwap = func(conn net.Conn) {
+		c.unixConn.Store(conn.(*net.UnixConn))
+	}
+	return c
+}
+
+// DialUnix returns a new *UnixClient.
+//
+// The new client connects to the remote address `raddr` on the network
+// `network`, which must be "unix" or "unixpacket".
+// If `laddr` is not nil, it is used as the local address for the connection.
+//
+// This overrides net.UnixConn's DialUnix function.
+func DialUnix(network string, laddr, raddr *net.UnixAddr) (*UnixClient, error) {
+	conn, err := net.DialUnix(network, laddr, raddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return newUnixClient(conn, unixDialer{network: network, laddr: laddr, raddr: raddr}), nil
+}
+
+// DialUnixContext is like DialUnix but uses ctx to bound the initial
+// connection attempt; it is canceled the same way net.Dialer.DialContext
+// would cancel it.
+func DialUnixContext(ctx context.Context, network string, laddr, raddr *net.UnixAddr) (*UnixClient, error) {
+	d := unixDialer{network: network, laddr: laddr, raddr: raddr}
+	conn, err := d.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newUnixClient(conn.(*net.UnixConn), d), nil
+}
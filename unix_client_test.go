@@ -0,0 +1,82 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ----------------------------------------------------------------------------
+
+func TestUnixClient_DialUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gas-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sockPath := filepath.Join(dir, "gas.sock")
+	laddr, err := net.ResolveUnixAddr("unix", sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.ListenUnix("unix", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := DialUnix("unix", nil, laddr)
+	if err != nil {
+		t.Error(err)
+	}
+	if c == nil || c.UnixConn() == nil {
+		t.Error("initialization failed")
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnixClient_reconnect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gas-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sockPath := filepath.Join(dir, "gas.sock")
+	laddr, err := net.ResolveUnixAddr("unix", sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.ListenUnix("unix", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := DialUnix("unix", nil, laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	conn1 := c.UnixConn()
+	if err := c.reconnect(); err != nil {
+		t.Error(err)
+	}
+	conn2 := c.UnixConn()
+	if conn2 == nil || conn1 == conn2 {
+		t.Error("reconnection failed")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// ----------------------------------------------------------------------------
+
+// writeBuffer is an in-memory FIFO byte queue used to absorb writes that
+// could not reach a live connection while a reconnect is in flight.
+type writeBuffer struct {
+	mu       sync.Mutex
+	buf      []byte
+	maxBytes int
+
+	flushing int32
+}
+
+func newWriteBuffer(maxBytes int) *writeBuffer {
+	return &writeBuffer{maxBytes: maxBytes}
+}
+
+// push appends b to the buffer, failing with ErrWriteBufferFull if doing so
+// would grow the buffer past maxBytes.
+func (wb *writeBuffer) push(b []byte) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	if len(wb.buf)+len(b) > wb.maxBytes {
+		return ErrWriteBufferFull
+	}
+	wb.buf = append(wb.buf, b...)
+	return nil
+}
+
+// pending reports the number of bytes currently queued.
+func (wb *writeBuffer) pending() int {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	return len(wb.buf)
+}
+
+// drain writes the queued bytes to conn, in order, removing them from the
+// queue as they are flushed. Any bytes that fail to be written are left in
+// place for a subsequent drain attempt.
+func (wb *writeBuffer) drain(conn net.Conn) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	for len(wb.buf) > 0 {
+		n, err := conn.Write(wb.buf)
+		wb.buf = wb.buf[n:]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startFlushing reports whether the caller is the one responsible for
+// draining the buffer, ensuring only one flusher goroutine runs at a time.
+func (wb *writeBuffer) startFlushing() bool {
+	return atomic.CompareAndSwapInt32(&wb.flushing, 0, 1)
+}
+
+// doneFlushing releases the flushing claim taken by startFlushing.
+func (wb *writeBuffer) doneFlushing() {
+	atomic.StoreInt32(&wb.flushing, 0)
+}
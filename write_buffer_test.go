@@ -0,0 +1,84 @@
+// Copyright © 2015 Clement 'cmc' Rey <cr.rey.clement@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package gas
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+
+func TestTCPClient_WriteBuffer(t *testing.T) {
+	s, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := s.Addr()
+
+	c, err := Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tc := c.(*TCPClient)
+	tc.SetMaxRetries(50)
+	tc.SetRetryInterval(10 * time.Millisecond)
+	tc.EnableWriteBuffer(len("hello, world!"))
+
+	// Kill the server so the write buffer, not a live connection, has to
+	// absorb the next writes.
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("hello, world!")
+	deadline := time.Now().Add(2 * time.Second)
+	for tc.Stats().BytesBuffered == 0 && time.Now().Before(deadline) {
+		if _, err := c.Write(payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tc.Stats().BytesBuffered == 0 {
+		t.Fatal("write buffer never absorbed a write against the dead server")
+	}
+
+	// The buffer only has room for one payload, so the next write must be
+	// rejected with ErrWriteBufferFull instead of blocking or growing past
+	// the configured size.
+	if _, err := c.Write(payload); err != ErrWriteBufferFull {
+		t.Errorf("got %v, want ErrWriteBufferFull", err)
+	}
+
+	// Bring the server back and confirm the queued bytes get flushed onto
+	// the new connection.
+	received := make(chan []byte, 1)
+	s2, err := net.Listen("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+	go func() {
+		conn, err := s2.Accept()
+		if err != nil {
+			return
+		}
+		buf := make([]byte, len(payload))
+		n, _ := conn.Read(buf)
+		received <- buf[:n]
+	}()
+
+	select {
+	case b := <-received:
+		if string(b) != string(payload) {
+			t.Errorf("got %q flushed, want %q", b, payload)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("write buffer was never flushed once the server came back")
+	}
+}